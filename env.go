@@ -0,0 +1,131 @@
+package runtime
+
+import (
+	"context"
+	"net"
+	"os"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+type contextKey int
+
+const (
+	// RequestIDContextKey is the context key under which GoWithID stores
+	// the unique id it generates for each invocation.
+	RequestIDContextKey contextKey = iota
+)
+
+// Option configures an Environment at construction time.
+type Option func(*Environment)
+
+// Environment supervises a set of goroutines sharing a cancellable
+// context, plus OS signal handling geared towards long running
+// services.
+type Environment struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	wg sync.WaitGroup
+
+	mu  sync.Mutex
+	err error
+
+	notify       *notifier
+	systemdAuto  bool
+	stoppingOnce sync.Once
+	initialTasks []func(context.Context) error
+
+	listeners []net.Listener
+
+	sigCh          chan os.Signal
+	sigMu          sync.Mutex
+	signalHandlers map[os.Signal]SignalHandlerFunc
+
+	shutdownOnce  sync.Once
+	shutdownMu    sync.Mutex
+	shutdownHooks map[int][]ShutdownHookFunc
+}
+
+// NewEnvironment creates an Environment whose context is derived from
+// ctx. Call Go/GoWithID to register goroutines and Wait to block until
+// they are done or the Environment is cancelled.
+func NewEnvironment(ctx context.Context, opts ...Option) *Environment {
+	cctx, cancel := context.WithCancel(ctx)
+
+	env := &Environment{
+		ctx:    cctx,
+		cancel: cancel,
+		notify: newNotifier(),
+	}
+
+	for _, opt := range opts {
+		opt(env)
+	}
+
+	initSignals(env)
+
+	if env.systemdAuto {
+		env.startSystemdNotify()
+	}
+
+	return env
+}
+
+// Go runs fn in a new goroutine bound to the Environment's context. If
+// fn returns a non-nil error, the Environment is cancelled with that
+// error.
+func (e *Environment) Go(fn func(context.Context) error) {
+	e.wg.Add(1)
+
+	go func() {
+		defer e.wg.Done()
+
+		if err := fn(e.ctx); err != nil {
+			e.Cancel(err)
+		}
+	}()
+}
+
+// GoWithID behaves like Go, but stores a freshly generated request id
+// in the context under RequestIDContextKey.
+func (e *Environment) GoWithID(fn func(context.Context) error) {
+	e.Go(func(ctx context.Context) error {
+		return fn(context.WithValue(ctx, RequestIDContextKey, uuid.New().String()))
+	})
+}
+
+// Cancel cancels the Environment's context. If err is non-nil and no
+// earlier error has been recorded, it becomes the error Wait returns.
+func (e *Environment) Cancel(err error) {
+	if err != nil {
+		e.mu.Lock()
+		if e.err == nil {
+			e.err = err
+		}
+		e.mu.Unlock()
+	}
+
+	e.notifyStopping()
+	e.cancel()
+}
+
+// Stop runs the registered shutdown phases (see OnShutdown), then
+// cancels the Environment, letting Wait return nil once all goroutines
+// have exited, unless a shutdown hook returned an error.
+func (e *Environment) Stop() {
+	e.shutdown(nil)
+}
+
+// Wait blocks until every goroutine registered with Go/GoWithID has
+// returned, then returns the first non-nil error passed to Cancel (by
+// such a goroutine, a stop signal, a shutdown hook or Exec), if any.
+func (e *Environment) Wait() error {
+	e.wg.Wait()
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return e.err
+}
@@ -4,10 +4,11 @@ import (
 	"context"
 	"errors"
 	"testing"
+	"time"
 )
 
 func TestEnvironmentStop(t *testing.T) {
-	t.Parallel()
+	t.Setenv(cancellationDelaySecondsEnv, "0")
 
 	env := NewEnvironment(context.Background())
 	waitCh := make(chan struct{})
@@ -92,7 +93,7 @@ func TestEnvironmentGo(t *testing.T) {
 }
 
 func TestEnvironmentID(t *testing.T) {
-	t.Parallel()
+	t.Setenv(cancellationDelaySecondsEnv, "0")
 
 	env := NewEnvironment(context.Background())
 
@@ -121,3 +122,62 @@ func TestEnvironmentID(t *testing.T) {
 		t.Error(`len(sid) != 36`)
 	}
 }
+
+func TestEnvironmentOnShutdownOrder(t *testing.T) {
+	t.Parallel()
+
+	env := NewEnvironment(context.Background())
+
+	var order []int
+
+	env.OnShutdown(1, func(ctx context.Context) error {
+		order = append(order, 1)
+		return nil
+	})
+	env.OnShutdown(0, func(ctx context.Context) error {
+		order = append(order, 0)
+		return nil
+	})
+
+	env.Stop()
+	if err := env.Wait(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(order) != 2 || order[0] != 0 || order[1] != 1 {
+		t.Errorf("hooks ran out of order: %v", order)
+	}
+}
+
+func TestEnvironmentOnShutdownError(t *testing.T) {
+	t.Parallel()
+
+	env := NewEnvironment(context.Background())
+
+	hookErr := errors.New("hook failed")
+	env.OnShutdown(0, func(ctx context.Context) error {
+		return hookErr
+	})
+
+	env.Stop()
+	err := env.Wait()
+	if !errors.Is(err, hookErr) {
+		t.Errorf("err does not wrap hookErr: %v", err)
+	}
+}
+
+func TestStopWithoutHooksHonorsCancellationDelay(t *testing.T) {
+	t.Setenv(cancellationDelaySecondsEnv, "1")
+
+	env := NewEnvironment(context.Background())
+
+	start := time.Now()
+	env.Stop()
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("Stop returned after %v, want at least the 1s CANCELLATION_DELAY_SECONDS", elapsed)
+	}
+
+	if err := env.Wait(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,193 @@
+package runtime
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"syscall"
+	"time"
+)
+
+// ExecOption configures Exec.
+type ExecOption func(*execOptions)
+
+type execOptions struct {
+	signals []os.Signal
+}
+
+// defaultExecSignals are the signals Exec forwards from this process
+// to the child by default, the same way container runtimes proxy
+// signals to PID 1 of a container. SIGURG is never forwarded: the Go
+// 1.14+ runtime uses it on Linux to preempt goroutines, and relaying
+// it would deliver spurious wakeups to the child.
+var defaultExecSignals = []os.Signal{
+	syscall.SIGINT,
+	syscall.SIGTERM,
+	syscall.SIGHUP,
+	syscall.SIGUSR1,
+	syscall.SIGUSR2,
+	syscall.SIGWINCH,
+}
+
+// terminalExecSignals ask the child to exit; if it hasn't by the time
+// the configured cancellation delay elapses, Exec escalates to
+// SIGKILL.
+var terminalExecSignals = map[os.Signal]bool{
+	syscall.SIGINT:  true,
+	syscall.SIGTERM: true,
+	syscall.SIGHUP:  true,
+}
+
+// ExecSignals overrides the set of signals Exec forwards to the
+// child, replacing defaultExecSignals.
+func ExecSignals(sigs ...os.Signal) ExecOption {
+	return func(o *execOptions) {
+		o.signals = sigs
+	}
+}
+
+// ExitError wraps the *exec.ExitError produced by a child run via Exec
+// so it can be told apart from a plain signal-driven shutdown of the
+// Environment itself. See IsSignaled and IsExitError.
+type ExitError struct {
+	*exec.ExitError
+}
+
+// IsExitError returns true if err is (or wraps) an *ExitError produced
+// by Exec.
+func IsExitError(err error) bool {
+	var ee *ExitError
+	return errors.As(err, &ee)
+}
+
+// Exec runs cmd as a supervised Environment task: while cmd is
+// running, every signal in opts (defaultExecSignals unless
+// overridden via ExecSignals) received by this process is forwarded
+// to cmd's PID. SIGINT, SIGTERM and SIGHUP additionally escalate to
+// SIGKILL if cmd has not exited within CANCELLATION_DELAY_SECONDS of
+// being forwarded. This lets Environment act as the supervisor
+// process in sidecar/init-style deployments.
+//
+// Exec returns once cmd has exited, ctx is cancelled, or the
+// Environment itself is torn down through any other path (another
+// goroutine's error, a shutdown hook, Stop). In the latter two cases
+// cmd is sent a terminal signal the same way an OS signal would be
+// forwarded, with the same SIGKILL escalation after
+// CANCELLATION_DELAY_SECONDS. A non-zero or signaled exit is returned
+// as an *ExitError; IsExitError and IsSignaled both work against the
+// error Exec returns, and against the one env.Wait() returns when Exec
+// is called from inside a Go/GoWithID func.
+func (e *Environment) Exec(ctx context.Context, cmd *exec.Cmd, opts ...ExecOption) error {
+	cfg := execOptions{signals: defaultExecSignals}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	signals := make([]os.Signal, 0, len(cfg.signals))
+	for _, sig := range cfg.signals {
+		if sig != syscall.SIGURG {
+			signals = append(signals, sig)
+		}
+	}
+	cfg.signals = signals
+
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+
+	restore := e.forwardSignalsTo(cmd, cfg.signals, done)
+	defer restore()
+
+	waitCh := make(chan error, 1)
+	go func() { waitCh <- cmd.Wait() }()
+
+	var err error
+	select {
+	case waitErr := <-waitCh:
+		err = toExecError(waitErr)
+	case <-ctx.Done():
+		_ = forwardSignal(cmd, syscall.SIGTERM, true, done)
+		err = toExecError(<-waitCh)
+	case <-e.ctx.Done():
+		_ = forwardSignal(cmd, syscall.SIGTERM, true, done)
+		err = toExecError(<-waitCh)
+	}
+
+	if err != nil {
+		e.Cancel(err)
+	}
+
+	return err
+}
+
+// forwardSignalsTo relays every signal in sigs to cmd's process for as
+// long as cmd is running (until done is closed), returning a func
+// that restores whatever handlers were registered for those signals
+// beforehand.
+func (e *Environment) forwardSignalsTo(cmd *exec.Cmd, sigs []os.Signal, done <-chan struct{}) func() {
+	prev := make(map[os.Signal]SignalHandlerFunc, len(sigs))
+
+	e.sigMu.Lock()
+	for _, sig := range sigs {
+		prev[sig] = e.signalHandlers[sig]
+	}
+	e.sigMu.Unlock()
+
+	for _, sig := range sigs {
+		terminal := terminalExecSignals[sig]
+
+		e.OnSignal(sig, func(_ context.Context, sig os.Signal) error {
+			return forwardSignal(cmd, sig, terminal, done)
+		})
+	}
+
+	return func() {
+		for _, sig := range sigs {
+			e.OnSignal(sig, prev[sig])
+		}
+	}
+}
+
+// forwardSignal sends sig to cmd's process and, if sig asks the
+// process to terminate, escalates to SIGKILL after
+// CANCELLATION_DELAY_SECONDS unless done is closed first.
+func forwardSignal(cmd *exec.Cmd, sig os.Signal, terminal bool, done <-chan struct{}) error {
+	if err := cmd.Process.Signal(sig); err != nil {
+		return err
+	}
+
+	if !terminal {
+		return nil
+	}
+
+	delay := time.Duration(getDelaySecondsFromEnv()) * time.Second
+
+	go func() {
+		select {
+		case <-done:
+		case <-time.After(delay):
+			_ = cmd.Process.Signal(syscall.SIGKILL)
+		}
+	}()
+
+	return nil
+}
+
+// toExecError turns the error cmd.Wait() returns into an *ExitError,
+// leaving any other error (e.g. cmd never started) untouched.
+func toExecError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var ee *exec.ExitError
+	if errors.As(err, &ee) {
+		return &ExitError{ExitError: ee}
+	}
+
+	return err
+}
@@ -0,0 +1,98 @@
+package runtime
+
+import (
+	"context"
+	"os/exec"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestExecCleanExit(t *testing.T) {
+	t.Setenv(cancellationDelaySecondsEnv, "0")
+
+	env := NewEnvironment(context.Background())
+	defer env.Stop()
+
+	cmd := exec.Command("true")
+
+	if err := env.Exec(context.Background(), cmd); err != nil {
+		t.Fatalf("expected a clean exit, got %v", err)
+	}
+}
+
+func TestExecNonZeroExit(t *testing.T) {
+	t.Setenv(cancellationDelaySecondsEnv, "0")
+
+	env := NewEnvironment(context.Background())
+	defer env.Stop()
+
+	cmd := exec.Command("sh", "-c", "exit 7")
+
+	err := env.Exec(context.Background(), cmd)
+	if err == nil {
+		t.Fatal("expected a non-nil error for a non-zero exit")
+	}
+	if !IsExitError(err) {
+		t.Fatalf("expected IsExitError to be true, got %v", err)
+	}
+	if IsSignaled(err) {
+		t.Fatal("a plain non-zero exit should not be reported as signaled")
+	}
+}
+
+func TestExecForwardsTerminalSignal(t *testing.T) {
+	t.Setenv(cancellationDelaySecondsEnv, "0")
+
+	env := NewEnvironment(context.Background())
+	defer env.Stop()
+
+	cmd := exec.Command("sleep", "5")
+
+	done := make(chan error, 1)
+	go func() {
+		done <- env.Exec(context.Background(), cmd)
+	}()
+
+	// Give Exec time to start cmd and install its signal forwarding
+	// before delivering the signal that should kill it.
+	time.Sleep(100 * time.Millisecond)
+
+	env.sigCh <- syscall.SIGTERM
+
+	select {
+	case err := <-done:
+		if !IsSignaled(err) {
+			t.Fatalf("expected the forwarded SIGTERM to signal the child, got %v", err)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("Exec did not return after the child was signaled")
+	}
+}
+
+func TestExecReturnsWhenContextCancelled(t *testing.T) {
+	t.Setenv(cancellationDelaySecondsEnv, "0")
+
+	env := NewEnvironment(context.Background())
+	defer env.Stop()
+
+	cmd := exec.Command("sleep", "5")
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	done := make(chan error, 1)
+	go func() {
+		done <- env.Exec(ctx, cmd)
+	}()
+
+	cancel()
+
+	select {
+	case err := <-done:
+		if !IsSignaled(err) {
+			t.Fatalf("expected ctx cancellation to signal the child, got %v", err)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("Exec did not return after its context was cancelled")
+	}
+}
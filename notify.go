@@ -0,0 +1,147 @@
+package runtime
+
+import (
+	"context"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	notifySocketEnv = "NOTIFY_SOCKET"
+	watchdogUsecEnv = "WATCHDOG_USEC"
+)
+
+// notifier speaks the subset of the sd_notify(3) protocol this package
+// needs. It is always constructed, and every method degrades to a
+// no-op when $NOTIFY_SOCKET is unset, so code using it behaves
+// identically under tests and non-systemd deployments.
+type notifier struct {
+	addr *net.UnixAddr
+}
+
+func newNotifier() *notifier {
+	socket := os.Getenv(notifySocketEnv)
+	if socket == "" {
+		return &notifier{}
+	}
+
+	return &notifier{addr: &net.UnixAddr{Name: socket, Net: "unixgram"}}
+}
+
+func (n *notifier) enabled() bool {
+	return n != nil && n.addr != nil
+}
+
+func (n *notifier) send(state string) error {
+	if !n.enabled() {
+		return nil
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, n.addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	_, err = conn.Write([]byte(state))
+	return err
+}
+
+// watchdogInterval returns the interval at which WATCHDOG=1 should be
+// sent, derived from $WATCHDOG_USEC as recommended by sd_notify(3):
+// ping at half the time systemd allows before considering the service
+// unresponsive.
+func (n *notifier) watchdogInterval() (time.Duration, bool) {
+	usecStr := os.Getenv(watchdogUsecEnv)
+	if usecStr == "" {
+		return 0, false
+	}
+
+	usec, err := strconv.ParseInt(usecStr, 10, 64)
+	if err != nil || usec <= 0 {
+		return 0, false
+	}
+
+	return time.Duration(usec) * time.Microsecond / 2, true
+}
+
+// WithSystemdNotify enables automatic sd_notify lifecycle reporting on
+// an Environment: READY=1 once every func in initial has started (not
+// merely been scheduled - each one signals its own start before
+// running its own body), STOPPING=1 once the Environment is cancelled,
+// and periodic WATCHDOG=1 pings when systemd sets $WATCHDOG_USEC. initial
+// is run the same way Go would run it; pass the funcs an Environment's
+// caller considers its startup set instead of calling Go for them
+// directly. When $NOTIFY_SOCKET is unset no notification is sent, but
+// initial is still run.
+func WithSystemdNotify(initial ...func(context.Context) error) Option {
+	return func(e *Environment) {
+		e.systemdAuto = true
+		e.initialTasks = initial
+	}
+}
+
+// startSystemdNotify wires up the watchdog goroutine (if systemd asked
+// for one) and the Environment's initial tasks, then reports readiness
+// once every initial task has started running. Called once, from
+// NewEnvironment, after signal handling has been installed.
+func (e *Environment) startSystemdNotify() {
+	if interval, ok := e.notify.watchdogInterval(); ok {
+		e.Go(func(ctx context.Context) error {
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+
+			for {
+				select {
+				case <-ctx.Done():
+					return nil
+				case <-ticker.C:
+					_ = e.notify.send("WATCHDOG=1")
+				}
+			}
+		})
+	}
+
+	var started sync.WaitGroup
+	started.Add(len(e.initialTasks))
+
+	for _, fn := range e.initialTasks {
+		fn := fn
+
+		e.Go(func(ctx context.Context) error {
+			started.Done()
+			return fn(ctx)
+		})
+	}
+
+	go func() {
+		started.Wait()
+		_ = e.NotifyReady()
+	}()
+}
+
+func (e *Environment) notifyStopping() {
+	if !e.systemdAuto {
+		return
+	}
+
+	e.stoppingOnce.Do(func() {
+		_ = e.notify.send("STOPPING=1")
+	})
+}
+
+// NotifyReady tells systemd the service has finished starting up (or
+// finished reloading). It is a no-op unless $NOTIFY_SOCKET is set.
+func (e *Environment) NotifyReady() error {
+	return e.notify.send("READY=1")
+}
+
+// NotifyStatus sets the freeform status text systemd shows for this
+// service (e.g. in `systemctl status`). It is a no-op unless
+// $NOTIFY_SOCKET is set.
+func (e *Environment) NotifyStatus(status string) error {
+	return e.notify.send("STATUS=" + status)
+}
@@ -0,0 +1,110 @@
+package runtime
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func readNotifyMessage(t *testing.T, conn *net.UnixConn, timeout time.Duration) (string, error) {
+	t.Helper()
+
+	_ = conn.SetReadDeadline(time.Now().Add(timeout))
+
+	buf := make([]byte, 64)
+	n, err := conn.Read(buf)
+	if err != nil {
+		return "", err
+	}
+
+	return string(buf[:n]), nil
+}
+
+func TestSystemdNotifyReadyWaitsForInitialTasks(t *testing.T) {
+	t.Setenv(cancellationDelaySecondsEnv, "0")
+
+	sockPath := filepath.Join(t.TempDir(), "notify.sock")
+
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: sockPath, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	t.Setenv("NOTIFY_SOCKET", sockPath)
+
+	startedCh := make(chan struct{})
+	releaseCh := make(chan struct{})
+
+	env := NewEnvironment(context.Background(), WithSystemdNotify(func(ctx context.Context) error {
+		close(startedCh)
+		<-releaseCh
+		return nil
+	}))
+	defer env.Stop()
+
+	<-startedCh
+
+	msg, err := readNotifyMessage(t, conn, time.Second)
+	if err != nil {
+		t.Fatalf("expected READY=1 once the initial task started, got error: %v", err)
+	}
+	if msg != "READY=1" {
+		t.Fatalf("got %q, want READY=1", msg)
+	}
+
+	close(releaseCh)
+	if err := env.Wait(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSystemdNotifyStoppingOnCancel(t *testing.T) {
+	t.Setenv(cancellationDelaySecondsEnv, "0")
+
+	sockPath := filepath.Join(t.TempDir(), "notify.sock")
+
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: sockPath, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	t.Setenv("NOTIFY_SOCKET", sockPath)
+
+	env := NewEnvironment(context.Background(), WithSystemdNotify())
+
+	if _, err := readNotifyMessage(t, conn, time.Second); err != nil {
+		t.Fatalf("expected an initial READY=1, got error: %v", err)
+	}
+
+	env.Stop()
+	if err := env.Wait(); err != nil {
+		t.Fatal(err)
+	}
+
+	msg, err := readNotifyMessage(t, conn, time.Second)
+	if err != nil {
+		t.Fatalf("expected STOPPING=1 after Stop, got error: %v", err)
+	}
+	if msg != "STOPPING=1" {
+		t.Fatalf("got %q, want STOPPING=1", msg)
+	}
+}
+
+func TestNotifyNoopWithoutSocket(t *testing.T) {
+	t.Setenv(cancellationDelaySecondsEnv, "0")
+	t.Setenv("NOTIFY_SOCKET", "")
+
+	env := NewEnvironment(context.Background())
+	defer env.Stop()
+
+	if err := env.NotifyReady(); err != nil {
+		t.Errorf("NotifyReady should no-op without NOTIFY_SOCKET: %v", err)
+	}
+	if err := env.NotifyStatus("starting"); err != nil {
+		t.Errorf("NotifyStatus should no-op without NOTIFY_SOCKET: %v", err)
+	}
+}
@@ -0,0 +1,144 @@
+package runtime
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+)
+
+const (
+	listenFdsEnv  = "LISTEN_FDS"
+	listenPidEnv  = "LISTEN_PID"
+	listenFdStart = 3
+
+	// selfManagedListenPid marks a LISTEN_FDS handoff performed by this
+	// package's own SIGHUP restart rather than by systemd itself.
+	// Unlike systemd, which forks the new process and so can stamp its
+	// real pid into LISTEN_PID before it execs, we hand off via
+	// os/exec and have no way to learn the child's pid early enough to
+	// write it into the child's environment. InheritListeners treats
+	// this sentinel as "skip the pid check" while still enforcing it
+	// for genuine systemd-driven socket activation.
+	selfManagedListenPid = "0"
+)
+
+// filer is implemented by the concrete listener types (*net.TCPListener,
+// *net.UnixListener, ...) that can hand out a dup'd file descriptor.
+type filer interface {
+	File() (*os.File, error)
+}
+
+// RegisterListener records l as one of the listeners that should be
+// handed off to a new process during a SIGHUP-triggered restart. See
+// InheritListeners for the other side of the handoff.
+func (e *Environment) RegisterListener(l net.Listener) {
+	e.mu.Lock()
+	e.listeners = append(e.listeners, l)
+	e.mu.Unlock()
+}
+
+// InheritListeners reconstructs the listeners a parent process passed
+// down via LISTEN_FDS/LISTEN_PID, mirroring sd_listen_fds(3). It
+// returns nil, nil if no listeners were inherited.
+func (e *Environment) InheritListeners() ([]net.Listener, error) {
+	nStr := os.Getenv(listenFdsEnv)
+	if nStr == "" {
+		return nil, nil
+	}
+
+	n, err := strconv.Atoi(nStr)
+	if err != nil || n <= 0 {
+		return nil, nil
+	}
+
+	if pidStr := os.Getenv(listenPidEnv); pidStr != "" && pidStr != selfManagedListenPid {
+		pid, err := strconv.Atoi(pidStr)
+		if err != nil || pid != os.Getpid() {
+			return nil, fmt.Errorf("runtime: LISTEN_PID %q does not match this process", pidStr)
+		}
+	}
+
+	listeners := make([]net.Listener, 0, n)
+	for i := 0; i < n; i++ {
+		file := os.NewFile(uintptr(listenFdStart+i), fmt.Sprintf("listener-%d", i))
+
+		l, err := net.FileListener(file)
+		file.Close()
+		if err != nil {
+			return listeners, err
+		}
+
+		listeners = append(listeners, l)
+	}
+
+	return listeners, nil
+}
+
+// restart execs a new copy of the running binary, handing off every
+// listener registered via RegisterListener so the replacement can
+// start accepting connections while this process drains and exits. It
+// reports the handoff to systemd as a reload: RELOADING=1 before the
+// new process is started, and MAINPID once it has been, so that
+// systemd (and any watchdog) tracks the replacement from here on. The
+// new process reports its own READY=1 once it starts up, the same way
+// any other Environment does.
+func (e *Environment) restart() error {
+	_ = e.notify.send("RELOADING=1")
+
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+
+	e.mu.Lock()
+	listeners := append([]net.Listener(nil), e.listeners...)
+	e.mu.Unlock()
+
+	extraFiles := make([]*os.File, 0, len(listeners))
+	for _, l := range listeners {
+		fl, ok := l.(filer)
+		if !ok {
+			closeFiles(extraFiles)
+			return fmt.Errorf("runtime: listener %T does not support file descriptor passing", l)
+		}
+
+		f, err := fl.File()
+		if err != nil {
+			closeFiles(extraFiles)
+			return err
+		}
+
+		extraFiles = append(extraFiles, f)
+	}
+
+	cmd := exec.Command(exe, os.Args[1:]...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.ExtraFiles = extraFiles
+	cmd.Env = append(os.Environ(),
+		fmt.Sprintf("%s=%d", listenFdsEnv, len(extraFiles)),
+		fmt.Sprintf("%s=%s", listenPidEnv, selfManagedListenPid),
+	)
+
+	if err := cmd.Start(); err != nil {
+		closeFiles(extraFiles)
+		return err
+	}
+
+	closeFiles(extraFiles)
+
+	return e.notify.send(fmt.Sprintf("MAINPID=%d", cmd.Process.Pid))
+}
+
+// closeFiles closes every file in files, ignoring errors. It's used to
+// release the dup'd listener fds restart collects for handoff, both
+// once the child has been started with them and when an attempt is
+// abandoned partway through.
+func closeFiles(files []*os.File) {
+	for _, f := range files {
+		f.Close()
+	}
+}
@@ -0,0 +1,110 @@
+package runtime
+
+import (
+	"context"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"testing"
+)
+
+func TestRegisterListener(t *testing.T) {
+	t.Setenv(cancellationDelaySecondsEnv, "0")
+
+	env := NewEnvironment(context.Background())
+	defer env.Stop()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	env.RegisterListener(l)
+
+	if len(env.listeners) != 1 {
+		t.Fatalf("got %d listeners, want 1", len(env.listeners))
+	}
+}
+
+func TestInheritListenersNoEnv(t *testing.T) {
+	t.Setenv(cancellationDelaySecondsEnv, "0")
+	t.Setenv(listenFdsEnv, "")
+
+	env := NewEnvironment(context.Background())
+	defer env.Stop()
+
+	listeners, err := env.InheritListeners()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if listeners != nil {
+		t.Fatalf("got %d listeners, want none", len(listeners))
+	}
+}
+
+func TestInheritListenersPidMismatch(t *testing.T) {
+	t.Setenv(cancellationDelaySecondsEnv, "0")
+	t.Setenv(listenFdsEnv, "1")
+	t.Setenv(listenPidEnv, strconv.Itoa(os.Getpid()+1))
+
+	env := NewEnvironment(context.Background())
+	defer env.Stop()
+
+	if _, err := env.InheritListeners(); err == nil {
+		t.Fatal("expected an error for a mismatched LISTEN_PID")
+	}
+}
+
+// TestInheritListenersSelfManaged exercises the real LISTEN_FDS/LISTEN_PID
+// handoff in a subprocess, since the fd numbers InheritListeners reads
+// (starting at listenFdStart) only line up with net.Listener.File() output
+// when the file is actually passed in as ExtraFiles[0] across an exec, the
+// same way restart() does it - dup'ing a listener onto an arbitrary fd of
+// this already-running test binary would collide with fds other tests and
+// the Go runtime have open.
+func TestInheritListenersSelfManaged(t *testing.T) {
+	t.Setenv(cancellationDelaySecondsEnv, "0")
+
+	if os.Getenv("RUNTIME_TEST_INHERIT_HELPER") == "1" {
+		env := NewEnvironment(context.Background())
+		defer env.Stop()
+
+		listeners, err := env.InheritListeners()
+		if err != nil {
+			os.Exit(1)
+		}
+		if len(listeners) != 1 {
+			os.Exit(2)
+		}
+
+		return
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	f, err := l.(*net.TCPListener).File()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	cmd := exec.Command(os.Args[0], "-test.run=TestInheritListenersSelfManaged")
+	cmd.ExtraFiles = []*os.File{f}
+	cmd.Env = append(os.Environ(),
+		"RUNTIME_TEST_INHERIT_HELPER=1",
+		listenFdsEnv+"=1",
+		listenPidEnv+"="+selfManagedListenPid,
+	)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("helper process failed to inherit the listener: %v", err)
+	}
+}
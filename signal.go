@@ -1,16 +1,30 @@
 package runtime
 
 import (
+	"context"
 	"errors"
-	"github.com/rs/zerolog/log"
 	"os"
 	"os/signal"
 	"strconv"
 	"syscall"
-	"time"
+
+	"github.com/rs/zerolog/log"
 )
 
-var stopSignals = []os.Signal{syscall.SIGINT, syscall.SIGTERM}
+// SignalHandlerFunc handles an OS signal received by an Environment.
+// It is passed the Environment's context so it can honor cancellation,
+// and the concrete signal that triggered it, which is useful when the
+// same func is registered for more than one signal.
+type SignalHandlerFunc func(ctx context.Context, sig os.Signal) error
+
+// defaultSignals are relayed to an Environment's dispatcher out of the
+// box; SIGINT/SIGTERM cancel the Environment after the configured
+// delay, SIGHUP performs a zero-downtime restart. Users can replace
+// any of these, or add more, via OnSignal. SIGURG is deliberately
+// never part of this set: the Go 1.14+ runtime uses it on Linux to
+// preempt goroutines, and relaying it would deliver spurious wakeups
+// to handler code.
+var defaultSignals = []os.Signal{syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP}
 
 var (
 	errSignaled = errors.New("signaled")
@@ -20,59 +34,48 @@ var (
 	defaultCancellationDelaySeconds = 5
 )
 
-// IsSignaled returns true if err returned by Wait indicates that
-// the program has received SIGINT or SIGTERM.
+// IsSignaled returns true if err returned by Wait indicates that the
+// program has received SIGINT, SIGTERM or SIGHUP (while their default
+// handlers are installed), or that a child process run
+// via Exec was killed by a signal (e.g. the SIGKILL escalation after
+// CANCELLATION_DELAY_SECONDS).
 func IsSignaled(err error) bool {
-	return err == errSignaled
-}
+	if errors.Is(err, errSignaled) {
+		return true
+	}
+
+	var ee *ExitError
+	if errors.As(err, &ee) {
+		if ws, ok := ee.ProcessState.Sys().(syscall.WaitStatus); ok {
+			return ws.Signaled()
+		}
+	}
 
-// handleSignal runs independent goroutine to cancel an environment.
-func handleSignal(env *Environment) {
-	ch := make(chan os.Signal, 2)
-	signal.Notify(ch, stopSignals...)
-
-	go func() {
-		s := <-ch
-		delay := getDelaySecondsFromEnv()
-		log.Warn().
-			Str("signal", s.String()).
-			Int("delay", delay).
-			Msg("[runtime] got signal")
-
-		time.Sleep(time.Duration(delay) * time.Second)
-		env.Cancel(errSignaled)
-	}()
+	return false
 }
 
-func getDelaySecondsFromEnv() int {
-	delayStr := os.Getenv(cancellationDelaySecondsEnv)
-	if len(delayStr) == 0 {
-		return defaultCancellationDelaySeconds
-	}
+// initSignals installs the default signal handlers and starts the
+// dispatcher goroutine that routes every subsequently received signal
+// to whatever OnSignal/IgnoreSignal last registered for it.
+func initSignals(env *Environment) {
+	env.sigCh = make(chan os.Signal, 2)
 
-	delay, err := strconv.Atoi(delayStr)
-	if err != nil {
-		log.Warn().Err(err).
-			Str("env", delayStr).
-			Int("delay", defaultCancellationDelaySeconds).
-			Msg("[runtime] set default cancellation delay seconds")
+	for _, sig := range defaultSignals {
+		if sig == syscall.SIGHUP {
+			env.OnSignal(sig, env.handleRestartSignal)
+			continue
+		}
 
-		return defaultCancellationDelaySeconds
+		env.OnSignal(sig, env.handleStopSignal)
 	}
 
-	if delay < 0 {
-		log.Warn().Err(err).
-			Str("env", delayStr).
-			Int("delay", 0).
-			Msg("[runtime] round up negative cancellation delay seconds to 0s")
-		return 0
-	}
+	ignoreSigPipe(env)
 
-	return delay
+	go env.dispatchSignals()
 }
 
-// handleSigPipe discards SIGPIPE if the program is running
-// as a systemd service.
+// ignoreSigPipe discards SIGPIPE if the program is running as a
+// systemd service.
 //
 // Background:
 //
@@ -108,9 +111,111 @@ func getDelaySecondsFromEnv() int {
 // Therefore, we just catch SIGPIPEs and drop them if the program
 // runs as a systemd service.  This way, we can detect journald restarts
 // by checking the errors from os.Stdout.Write or os.Stderr.Write.
-func handleSigPipe() {
-	// signal.Ignore does NOT ignore signals; instead, it just stop
-	// relaying signals to the channel.  Instead, we set a nop handler.
-	c := make(chan os.Signal, 1)
-	signal.Notify(c, syscall.SIGPIPE)
+func ignoreSigPipe(env *Environment) {
+	env.IgnoreSignal(syscall.SIGPIPE)
+}
+
+// OnSignal registers fn as the handler for sig, replacing whatever was
+// registered before it, including the defaults installed by
+// NewEnvironment. fn runs on the dispatcher goroutine, so it should
+// not block for long.
+func (e *Environment) OnSignal(sig os.Signal, fn SignalHandlerFunc) {
+	e.sigMu.Lock()
+	if e.signalHandlers == nil {
+		e.signalHandlers = make(map[os.Signal]SignalHandlerFunc)
+	}
+	e.signalHandlers[sig] = fn
+	e.sigMu.Unlock()
+
+	// Reset drops sig's previous relay, including one left over from a
+	// prior IgnoreSignal(sig), so exactly one channel - e.sigCh - ever
+	// receives it.
+	signal.Reset(sig)
+	signal.Notify(e.sigCh, sig)
+}
+
+// IgnoreSignal drops sig instead of letting its default OS action run.
+// It mirrors the trick this package has always used to survive
+// SIGPIPE: relay the signal to a channel nothing ever reads from.
+// OnSignal and IgnoreSignal are mutually exclusive per signal - calling
+// one clears whatever the other left registered for the same sig.
+func (e *Environment) IgnoreSignal(sig os.Signal) {
+	e.sigMu.Lock()
+	delete(e.signalHandlers, sig)
+	e.sigMu.Unlock()
+
+	signal.Reset(sig)
+	signal.Notify(make(chan os.Signal, 1), sig)
+}
+
+func (e *Environment) dispatchSignals() {
+	for {
+		select {
+		case <-e.ctx.Done():
+			return
+		case sig := <-e.sigCh:
+			e.sigMu.Lock()
+			fn := e.signalHandlers[sig]
+			e.sigMu.Unlock()
+
+			if fn == nil {
+				continue
+			}
+
+			if err := fn(e.ctx, sig); err != nil {
+				log.Error().Err(err).Str("signal", sig.String()).Msg("[runtime] signal handler failed")
+			}
+		}
+	}
+}
+
+// handleStopSignal is the default SIGINT/SIGTERM handler: it runs the
+// registered shutdown phases (see OnShutdown), then cancels the
+// Environment so goroutines spawned via Go/GoWithID are torn down only
+// once the drain sequence above them has finished.
+func (e *Environment) handleStopSignal(_ context.Context, sig os.Signal) error {
+	log.Warn().Str("signal", sig.String()).Msg("[runtime] got signal")
+
+	e.shutdown(errSignaled)
+
+	return nil
+}
+
+// handleRestartSignal is the default SIGHUP handler: it hands off any
+// registered listeners to a freshly exec'd copy of the binary, then
+// falls back to the normal stop delay so this process keeps draining
+// in-flight work while the replacement takes over.
+func (e *Environment) handleRestartSignal(ctx context.Context, sig os.Signal) error {
+	if err := e.restart(); err != nil {
+		log.Error().Err(err).Msg("[runtime] restart failed")
+	}
+
+	return e.handleStopSignal(ctx, sig)
+}
+
+func getDelaySecondsFromEnv() int {
+	delayStr := os.Getenv(cancellationDelaySecondsEnv)
+	if len(delayStr) == 0 {
+		return defaultCancellationDelaySeconds
+	}
+
+	delay, err := strconv.Atoi(delayStr)
+	if err != nil {
+		log.Warn().Err(err).
+			Str("env", delayStr).
+			Int("delay", defaultCancellationDelaySeconds).
+			Msg("[runtime] set default cancellation delay seconds")
+
+		return defaultCancellationDelaySeconds
+	}
+
+	if delay < 0 {
+		log.Warn().Err(err).
+			Str("env", delayStr).
+			Int("delay", 0).
+			Msg("[runtime] round up negative cancellation delay seconds to 0s")
+		return 0
+	}
+
+	return delay
 }
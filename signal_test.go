@@ -0,0 +1,56 @@
+package runtime
+
+import (
+	"context"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestOnSignalDispatch(t *testing.T) {
+	t.Setenv(cancellationDelaySecondsEnv, "0")
+
+	env := NewEnvironment(context.Background())
+	defer env.Stop()
+
+	gotCh := make(chan os.Signal, 1)
+	env.OnSignal(syscall.SIGUSR1, func(_ context.Context, sig os.Signal) error {
+		gotCh <- sig
+		return nil
+	})
+
+	env.sigCh <- syscall.SIGUSR1
+
+	select {
+	case sig := <-gotCh:
+		if sig != syscall.SIGUSR1 {
+			t.Fatalf("got %v, want SIGUSR1", sig)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("handler was never invoked")
+	}
+}
+
+func TestIgnoreSignalClearsHandler(t *testing.T) {
+	t.Setenv(cancellationDelaySecondsEnv, "0")
+
+	env := NewEnvironment(context.Background())
+	defer env.Stop()
+
+	calledCh := make(chan struct{}, 1)
+	env.OnSignal(syscall.SIGUSR2, func(_ context.Context, _ os.Signal) error {
+		calledCh <- struct{}{}
+		return nil
+	})
+
+	env.IgnoreSignal(syscall.SIGUSR2)
+
+	env.sigCh <- syscall.SIGUSR2
+
+	select {
+	case <-calledCh:
+		t.Fatal("handler ran after IgnoreSignal, should have been cleared")
+	case <-time.After(100 * time.Millisecond):
+	}
+}